@@ -1,6 +1,9 @@
 package api
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"log/slog"
 
@@ -52,3 +55,79 @@ func (c *Client) ExecuteAgent(payload map[string]interface{}) ([]interface{}, er
 
 	return result, nil
 }
+
+// Attachment is a media file (image, audio, or document) accompanying a
+// streamed reply. It mirrors the shape ExecuteAgent's non-streaming response
+// messages carry under "attachments".
+type Attachment struct {
+	Type string `json:"type"`
+	Mime string `json:"mime"`
+	URL  string `json:"url"`
+}
+
+// ChatMessageDelta is one incremental chunk of a streamed Core API reply.
+// Attachments, when present, are forwarded on once the full reply is known
+// since Telegram has no API for attaching media to an in-progress edit.
+type ChatMessageDelta struct {
+	Text        string       `json:"text"`
+	Done        bool         `json:"done"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// ExecuteAgentStream calls /core/execute with streaming requested and invokes
+// onChunk for every delta frame (newline-delimited JSON) as it arrives, so
+// callers can progressively reveal the reply instead of waiting for it in
+// full. A 404 or 501 status is returned as an error, so the caller can fall
+// back to ExecuteAgent. Any other successful response is read as frames and
+// lines that don't decode as a delta are skipped, so a plain non-streaming
+// JSON reply produces no chunks and a nil error.
+func (c *Client) ExecuteAgentStream(payload map[string]interface{}, onChunk func(ChatMessageDelta) error) error {
+	streamPayload := make(map[string]interface{}, len(payload)+1)
+	for k, v := range payload {
+		streamPayload[k] = v
+	}
+	streamPayload["stream"] = true
+
+	resp, err := c.client.R().
+		SetHeader("Content-Type", "application/json").
+		SetHeader("Accept", "application/x-ndjson").
+		SetDoNotParseResponse(true).
+		SetBody(streamPayload).
+		Post(c.baseURL + "/core/execute")
+	if err != nil {
+		return fmt.Errorf("failed to execute agent stream: %w", err)
+	}
+	body := resp.RawBody()
+	defer body.Close()
+
+	if resp.StatusCode() == 404 || resp.StatusCode() == 501 {
+		return fmt.Errorf("core api does not support streaming (status %d)", resp.StatusCode())
+	}
+	if resp.IsError() {
+		return fmt.Errorf("core api returned error status: %d", resp.StatusCode())
+	}
+
+	scanner := bufio.NewScanner(body)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		line = bytes.TrimPrefix(line, []byte("data:"))
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+
+		var delta ChatMessageDelta
+		if err := json.Unmarshal(line, &delta); err != nil {
+			slog.Warn("Skipping malformed stream frame", "error", err)
+			continue
+		}
+		if err := onChunk(delta); err != nil {
+			return err
+		}
+		if delta.Done {
+			break
+		}
+	}
+	return scanner.Err()
+}
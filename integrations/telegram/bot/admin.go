@@ -0,0 +1,188 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/bot/router"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
+)
+
+// totpCodePattern matches a bare totpDigits-digit authenticator code, with no
+// other surrounding text.
+var totpCodePattern = regexp.MustCompile(fmt.Sprintf(`^\d{%d}$`, totpDigits))
+
+// pendingChallenge is a sensitive command waiting on its admin to reply with
+// a TOTP code, keyed by challengeKey(agentID, chatID, userID).
+type pendingChallenge struct {
+	run     func(ctx *router.Context) error
+	ctx     *router.Context
+	seed    string
+	expires time.Time
+}
+
+func challengeKey(agentID string, chatID, userID int64) string {
+	return fmt.Sprintf("%s:%d:%d", agentID, chatID, userID)
+}
+
+// requireTOTP wraps an admin command's handler so it only runs once the
+// caller has confirmed it with a 6-digit code from their authenticator app.
+// The first invocation DMs the challenge and returns; the code itself is
+// consumed out of band, by handlePendingChallenge on the admin's next message.
+func (m *Manager) requireTOTP(agentID string, next func(ctx *router.Context) error) func(ctx *router.Context) error {
+	return func(ctx *router.Context) error {
+		var admin store.AgentAdmin
+		err := m.db.Where("agent_id = ? AND user_id = ?", agentID, ctx.UserID).First(&admin).Error
+		if err != nil || admin.EncryptedTOTPSeed == "" {
+			_, sendErr := ctx.Sender.Send(ctx.ChatID, "This command requires 2FA, but you aren't enrolled. Run /enroll2fa first.")
+			return sendErr
+		}
+
+		seed, err := decryptSecret(m.cfg.TgSessionEncryptionKey, admin.EncryptedTOTPSeed)
+		if err != nil {
+			slog.Error("Failed to decrypt TOTP seed", "agent_id", agentID, "user_id", ctx.UserID, "error", err)
+			_, sendErr := ctx.Sender.Send(ctx.ChatID, "This command requires 2FA, but your enrollment couldn't be read. Try /enroll2fa again.")
+			return sendErr
+		}
+
+		// The challenge goes to the admin's private chat (whose chat ID is
+		// their user ID), which is where handlePendingChallenge expects the code.
+		key := challengeKey(agentID, ctx.UserID, ctx.UserID)
+		m.mu.Lock()
+		m.pendingChallenges[key] = &pendingChallenge{
+			run:     next,
+			ctx:     ctx,
+			seed:    string(seed),
+			expires: time.Now().Add(time.Duration(m.cfg.TgAdminChallengeTTLMinutes) * time.Minute),
+		}
+		m.mu.Unlock()
+
+		_, err = ctx.Sender.Send(ctx.UserID, "This action requires confirmation. Reply with the 6-digit code from your authenticator app.")
+		return err
+	}
+}
+
+// handlePendingChallenge completes a requireTOTP challenge if message looks
+// like a reply to one: a bare 6-digit code sent by the admin who triggered
+// it, in their private chat with the agent. It reports true when it consumed the message, whether or not
+// the code was valid, so the caller should stop processing either way. A
+// pending challenge is left in place if the admin sends anything that isn't
+// a 6-digit code first, so it's still there once they do reply with one.
+func (m *Manager) handlePendingChallenge(session Session, message IncomingMessage, agentID string) bool {
+	key := challengeKey(agentID, message.ChatID, message.UserID)
+
+	m.mu.RLock()
+	_, ok := m.pendingChallenges[key]
+	m.mu.RUnlock()
+	if !ok {
+		return false
+	}
+
+	if !totpCodePattern.MatchString(strings.TrimSpace(message.Text)) {
+		return false
+	}
+
+	m.mu.Lock()
+	challenge, ok := m.pendingChallenges[key]
+	if ok {
+		delete(m.pendingChallenges, key)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+	if time.Now().After(challenge.expires) {
+		_, _ = session.Send(message.ChatID, "That confirmation code expired; please run the command again.")
+		return true
+	}
+	if !validateTOTPCode(challenge.seed, message.Text) {
+		_, _ = session.Send(message.ChatID, "Invalid code.")
+		return true
+	}
+
+	if err := challenge.run(challenge.ctx); err != nil {
+		slog.Error("Confirmed admin command failed", "agent_id", agentID, "error", err)
+	}
+	return true
+}
+
+// enrollAdmin self-enrolls the calling admin in TOTP for agentID: it's the
+// only way an admin gets a seed without another admin inserting one into
+// agent_admins by hand, which is otherwise required before requireTOTP-gated
+// commands (e.g. /broadcast, /reload) will let them through.
+func (m *Manager) enrollAdmin(agentID string, ctx *router.Context) error {
+	var admin store.AgentAdmin
+	if err := m.db.FirstOrCreate(&admin, store.AgentAdmin{AgentID: agentID, UserID: ctx.UserID}).Error; err != nil {
+		return err
+	}
+	if admin.EncryptedTOTPSeed != "" {
+		_, err := ctx.Sender.Send(ctx.ChatID, "You're already enrolled in 2FA for this agent.")
+		return err
+	}
+
+	seed, err := generateTOTPSecret()
+	if err != nil {
+		return err
+	}
+	// Encrypted the same way agent_sessions.encrypted_session is: a DB read
+	// alone shouldn't be enough to defeat the 2FA gate on /broadcast/reload.
+	encSeed, err := encryptSecret(m.cfg.TgSessionEncryptionKey, []byte(seed))
+	if err != nil {
+		return err
+	}
+	if err := m.db.Model(&store.AgentAdmin{}).
+		Where("agent_id = ? AND user_id = ?", agentID, ctx.UserID).
+		Update("encrypted_totp_seed", encSeed).Error; err != nil {
+		return err
+	}
+
+	uri := totpProvisioningURI(agentID, ctx.UserID, seed)
+	_, err = ctx.Sender.Send(ctx.ChatID, fmt.Sprintf(
+		"2FA enrolled. Add this to your authenticator app:\n\nSecret: %s\n\nOr import it directly:\n%s",
+		seed, uri))
+	return err
+}
+
+// refreshAgentConfig reloads the per-agent settings that can change without a
+// restart — the command registry (admins, custom commands) and the rate
+// limit — on every syncBots tick.
+func (m *Manager) refreshAgentConfig(agent *store.Agent) {
+	registry := m.buildRegistry(agent)
+	m.mu.Lock()
+	m.registries[agent.ID] = registry
+	m.mu.Unlock()
+
+	m.setRateLimit(agent.ID, rateLimitConfigFromAgent(agent.TelegramConfig, m.cfg))
+}
+
+// broadcast sends text to every chat the agent has ever talked to, per
+// message_links, and is gated behind requireTOTP because it fans out to
+// every known user.
+func (m *Manager) broadcast(agentID, text string) error {
+	var chatIDs []string
+	if err := m.db.Model(&store.MessageLink{}).Where("agent_id = ?", agentID).Distinct().Pluck("chat_id", &chatIDs).Error; err != nil {
+		return err
+	}
+
+	m.mu.RLock()
+	session := m.sessions[agentID]
+	m.mu.RUnlock()
+	if session == nil {
+		return fmt.Errorf("agent %s has no running telegram session", agentID)
+	}
+
+	for _, chatID := range chatIDs {
+		var rawChatID int64
+		if _, err := fmt.Sscanf(chatID, "%d", &rawChatID); err != nil {
+			continue
+		}
+		if _, err := session.Send(rawChatID, text); err != nil {
+			slog.Warn("Failed to deliver broadcast", "agent_id", agentID, "chat_id", chatID, "error", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package bot
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+// Attachment is forwarded to the Core API alongside (or instead of) text for
+// multimodal messages.
+type Attachment struct {
+	Type string `json:"type"` // "image", "audio", or "document"
+	Mime string `json:"mime"`
+	Data string `json:"data,omitempty"` // base64-encoded file contents
+	URL  string `json:"url,omitempty"`
+}
+
+// extractAttachments looks at the raw Telegram message behind an
+// IncomingMessage (only populated for BotDriver sessions) for voice, audio,
+// photo or document payloads, downloads whichever is present, and either
+// transcribes it (voice/audio, when a Transcriber is configured) or returns
+// it as an Attachment to forward to the Core API.
+func (m *Manager) extractAttachments(session Session, message IncomingMessage) ([]Attachment, string, error) {
+	bd, ok := session.(*BotDriver)
+	if !ok || message.Message == nil {
+		return nil, "", nil
+	}
+	msg := message.Message
+
+	switch {
+	case msg.Voice != nil:
+		return m.downloadAudio(bd, msg.Voice.FileID, msg.Voice.MimeType)
+	case msg.Audio != nil:
+		return m.downloadAudio(bd, msg.Audio.FileID, msg.Audio.MimeType)
+	case len(msg.Photo) > 0:
+		// Telegram returns photo sizes smallest-first; the last is the largest.
+		largest := msg.Photo[len(msg.Photo)-1]
+		attachment, err := m.downloadAttachment(bd, "image", largest.FileID, "image/jpeg")
+		return wrapAttachment(attachment), "", err
+	case msg.Document != nil:
+		attachment, err := m.downloadAttachment(bd, "document", msg.Document.FileID, msg.Document.MimeType)
+		return wrapAttachment(attachment), "", err
+	default:
+		return nil, "", nil
+	}
+}
+
+func (m *Manager) downloadAudio(bd *BotDriver, fileID, mimeType string) ([]Attachment, string, error) {
+	data, mime, err := bd.DownloadFile(fileID, mimeType, m.cfg.MaxAttachmentSizeBytes())
+	if err != nil {
+		return nil, "", err
+	}
+	if !allowedMimeType(mime, m.cfg.TgAllowedAttachmentMimeTypes) {
+		return nil, "", fmt.Errorf("mime type %q is not allowed", mime)
+	}
+
+	if m.transcriber != nil {
+		text, err := m.transcriber.Transcribe(context.Background(), data, mime)
+		if err != nil {
+			slog.Warn("Failed to transcribe audio, forwarding as attachment instead", "error", err)
+		} else {
+			return nil, text, nil
+		}
+	}
+
+	return []Attachment{{Type: "audio", Mime: mime, Data: base64.StdEncoding.EncodeToString(data)}}, "", nil
+}
+
+func (m *Manager) downloadAttachment(bd *BotDriver, kind, fileID, mimeType string) (*Attachment, error) {
+	data, mime, err := bd.DownloadFile(fileID, mimeType, m.cfg.MaxAttachmentSizeBytes())
+	if err != nil {
+		return nil, err
+	}
+	if !allowedMimeType(mime, m.cfg.TgAllowedAttachmentMimeTypes) {
+		return nil, fmt.Errorf("mime type %q is not allowed", mime)
+	}
+	return &Attachment{Type: kind, Mime: mime, Data: base64.StdEncoding.EncodeToString(data)}, nil
+}
+
+func wrapAttachment(a *Attachment) []Attachment {
+	if a == nil {
+		return nil
+	}
+	return []Attachment{*a}
+}
+
+func allowedMimeType(mime, allowList string) bool {
+	if allowList == "" {
+		return true
+	}
+	for _, m := range strings.Split(allowList, ",") {
+		if strings.TrimSpace(m) == mime {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,316 @@
+package bot
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/bot/router"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/config"
+	"github.com/mymmrac/telego"
+	tu "github.com/mymmrac/telego/telegoutil"
+)
+
+// BotDriver implements Session on top of the classic Telegram Bot API,
+// using either long polling or the shared webhook server depending on cfg.TgMode.
+type BotDriver struct {
+	bot     *telego.Bot
+	agentID string
+	cfg     *config.Config
+	webhook *webhookServer
+	secret  string
+	cancel  context.CancelFunc
+	updates chan IncomingMessage
+
+	// closeMu guards against closing updates while dispatch is mid-send:
+	// dispatch holds the read lock for the duration of its send (so Stop's
+	// write lock can't be acquired, and the channel closed, until any
+	// in-flight send has finished), and checks closed before sending so it
+	// never sends on an already-closed channel.
+	closeMu sync.RWMutex
+	closed  bool
+}
+
+func newBotDriver(agentID, token string, cfg *config.Config, webhook *webhookServer) (*BotDriver, error) {
+	b, err := telego.NewBot(token, telego.WithDefaultDebugLogger())
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bot: %w", err)
+	}
+	return &BotDriver{
+		bot:     b,
+		agentID: agentID,
+		cfg:     cfg,
+		webhook: webhook,
+		updates: make(chan IncomingMessage, 64),
+	}, nil
+}
+
+func (d *BotDriver) Start(ctx context.Context) error {
+	if d.cfg.TgMode == "webhook" && d.webhook != nil {
+		if err := d.startWebhook(); err == nil {
+			return nil
+		} else if !d.cfg.TgWebhookFallbackToPolling {
+			return err
+		} else {
+			slog.Warn("Failed to set webhook, falling back to long polling", "agent_id", d.agentID, "error", err)
+		}
+	}
+	return d.startPolling(ctx)
+}
+
+func (d *BotDriver) startPolling(ctx context.Context) error {
+	pollCtx, cancel := context.WithCancel(ctx)
+	updates, err := d.bot.UpdatesViaLongPolling(pollCtx, nil)
+	if err != nil {
+		cancel()
+		return fmt.Errorf("failed to get updates channel: %w", err)
+	}
+	d.cancel = cancel
+
+	go func() {
+		for update := range updates {
+			d.dispatch(update)
+		}
+	}()
+	return nil
+}
+
+func (d *BotDriver) startWebhook() error {
+	secret, err := randomSecret()
+	if err != nil {
+		return fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/tg/%s/%s", d.cfg.TgWebhookBaseURL, d.agentID, secret)
+	if err := d.bot.SetWebhook(context.Background(), &telego.SetWebhookParams{URL: url, SecretToken: secret}); err != nil {
+		return fmt.Errorf("failed to set webhook: %w", err)
+	}
+
+	d.secret = secret
+	d.webhook.register(d.agentID, secret, d.dispatch)
+	return nil
+}
+
+func (d *BotDriver) dispatch(update telego.Update) {
+	switch {
+	case update.Message != nil:
+		msg := update.Message
+		im := IncomingMessage{
+			ChatID:    msg.Chat.ID,
+			Text:      msg.Text,
+			MessageID: msg.MessageID,
+			Message:   msg,
+		}
+		if msg.From != nil {
+			im.UserID = msg.From.ID
+			im.Username = msg.From.Username
+		}
+		if msg.ReplyToMessage != nil {
+			im.ReplyToID = msg.ReplyToMessage.MessageID
+		}
+		d.send(im)
+
+	case update.CallbackQuery != nil:
+		cq := update.CallbackQuery
+		im := IncomingMessage{
+			IsCallback:   true,
+			CallbackData: cq.Data,
+			CallbackID:   cq.ID,
+			UserID:       cq.From.ID,
+			Username:     cq.From.Username,
+		}
+		if cq.Message != nil {
+			im.ChatID = cq.Message.GetChat().ID
+			im.MessageID = cq.Message.GetMessageID()
+		}
+		d.send(im)
+	}
+}
+
+// send forwards im on updates, unless Stop has already closed it. Holding
+// closeMu for read lets multiple dispatch calls (long polling and the shared
+// webhook server both call dispatch concurrently) send at once, while
+// guaranteeing none of them is still sending once Stop takes the write lock.
+func (d *BotDriver) send(im IncomingMessage) {
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return
+	}
+	d.updates <- im
+}
+
+// AnswerCallback acknowledges a CallbackQuery so Telegram stops showing the
+// button's loading spinner.
+func (d *BotDriver) AnswerCallback(callbackID string) error {
+	return d.bot.AnswerCallbackQuery(context.Background(), tu.CallbackQuery(callbackID))
+}
+
+// SendWithKeyboard implements router.KeyboardSender.
+func (d *BotDriver) SendWithKeyboard(chatID int64, text string, buttons []router.Button) (int, error) {
+	rows := make([][]telego.InlineKeyboardButton, 0, len(buttons))
+	for _, b := range buttons {
+		rows = append(rows, []telego.InlineKeyboardButton{tu.InlineKeyboardButton(b.Text).WithCallbackData(b.Data)})
+	}
+
+	msg, err := d.bot.SendMessage(context.Background(), tu.Message(tu.ID(chatID), text).WithReplyMarkup(tu.InlineKeyboard(rows...)))
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+// SetCommands registers the agent's non-admin commands so Telegram shows
+// them in the client's command menu.
+func (d *BotDriver) SetCommands(cmds []*router.Command) error {
+	botCmds := make([]telego.BotCommand, 0, len(cmds))
+	for _, cmd := range cmds {
+		if cmd.AdminOnly {
+			continue
+		}
+		botCmds = append(botCmds, telego.BotCommand{Command: cmd.Name, Description: cmd.Description})
+	}
+	return d.bot.SetMyCommands(context.Background(), &telego.SetMyCommandsParams{Commands: botCmds})
+}
+
+func (d *BotDriver) Stop() error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+
+	var unregisterErr error
+	if d.secret != "" && d.webhook != nil {
+		// Unregister first so the webhook server stops routing new requests
+		// to us; any request already in dispatch is still safe, since send
+		// serializes against the close below via closeMu.
+		d.webhook.unregister(d.agentID, d.secret)
+		unregisterErr = d.bot.DeleteWebhook(context.Background(), &telego.DeleteWebhookParams{})
+	}
+
+	d.closeMu.Lock()
+	d.closed = true
+	close(d.updates)
+	d.closeMu.Unlock()
+
+	return unregisterErr
+}
+
+func (d *BotDriver) Send(chatID int64, text string) (int, error) {
+	msg, err := d.bot.SendMessage(context.Background(), tu.Message(tu.ID(chatID), text))
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+func (d *BotDriver) Edit(chatID int64, messageID int, text string) error {
+	_, err := d.bot.EditMessageText(context.Background(), &telego.EditMessageTextParams{
+		ChatID:    tu.ID(chatID),
+		MessageID: messageID,
+		Text:      text,
+	})
+	return err
+}
+
+func (d *BotDriver) Updates() <-chan IncomingMessage {
+	return d.updates
+}
+
+func (d *BotDriver) WhoAmI(ctx context.Context) (int64, string, string, error) {
+	me, err := d.bot.GetMe(ctx)
+	if err != nil {
+		return 0, "", "", err
+	}
+	fullName := me.FirstName
+	if me.LastName != "" {
+		fullName = me.FirstName + " " + me.LastName
+	}
+	return me.ID, me.Username, fullName, nil
+}
+
+// Bot exposes the underlying telego.Bot for bot-only features (e.g. typing
+// indicators) that have no UserDriver equivalent and so aren't part of the
+// Session interface.
+func (d *BotDriver) Bot() *telego.Bot {
+	return d.bot
+}
+
+// DownloadFile fetches a Telegram-hosted file by ID via GetFile + HTTP,
+// rejecting anything over maxSize bytes. fallbackMime is returned verbatim
+// when non-empty; otherwise the type is sniffed from the content.
+func (d *BotDriver) DownloadFile(fileID, fallbackMime string, maxSize int64) ([]byte, string, error) {
+	file, err := d.bot.GetFile(context.Background(), &telego.GetFileParams{FileID: fileID})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get file info: %w", err)
+	}
+	if maxSize > 0 && int64(file.FileSize) > maxSize {
+		return nil, "", fmt.Errorf("file exceeds max attachment size of %d bytes", maxSize)
+	}
+
+	resp, err := http.Get(d.bot.FileDownloadURL(file.FilePath))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download file: %w", err)
+	}
+	defer resp.Body.Close()
+
+	limit := maxSize
+	if limit <= 0 {
+		limit = defaultMaxDownloadBytes
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read file body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, "", fmt.Errorf("file exceeds max attachment size of %d bytes", limit)
+	}
+
+	mime := fallbackMime
+	if mime == "" {
+		mime = http.DetectContentType(data)
+	}
+	return data, mime, nil
+}
+
+// SendPhotoURL sends a remote image as a Telegram photo message.
+func (d *BotDriver) SendPhotoURL(chatID int64, url, caption string) (int, error) {
+	msg, err := d.bot.SendPhoto(context.Background(), &telego.SendPhotoParams{
+		ChatID:  tu.ID(chatID),
+		Photo:   telego.InputFile{URL: url},
+		Caption: caption,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+// SendVoiceURL sends a remote audio clip as a Telegram voice message.
+func (d *BotDriver) SendVoiceURL(chatID int64, url, caption string) (int, error) {
+	msg, err := d.bot.SendVoice(context.Background(), &telego.SendVoiceParams{
+		ChatID:  tu.ID(chatID),
+		Voice:   telego.InputFile{URL: url},
+		Caption: caption,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return msg.MessageID, nil
+}
+
+// defaultMaxDownloadBytes caps downloads when no per-deployment limit is
+// configured (TgMaxAttachmentSizeMB <= 0).
+const defaultMaxDownloadBytes = 50 * 1024 * 1024
+
+func randomSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,176 @@
+package bot
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/bot/router"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
+)
+
+// buildRegistry assembles the slash-command router for one agent: the
+// built-ins every agent gets, plus whatever custom commands it declared in
+// TelegramConfig["commands"].
+func (m *Manager) buildRegistry(agent *store.Agent) *router.Registry {
+	reg := router.NewRegistry()
+	reg.SetAdmins(adminIDsFromConfig(agent.TelegramConfig))
+
+	reg.Register(&router.Command{
+		Name:        "start",
+		Description: "Start chatting with this agent",
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Sender.Send(ctx.ChatID, "Hi! Send me a message to get started.")
+			return err
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "help",
+		Description: "List available commands",
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Sender.Send(ctx.ChatID, helpText(reg))
+			return err
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "reset",
+		Description: "Forget this conversation's history",
+		Handler: func(ctx *router.Context) error {
+			chatID := chatIDString(ctx.ChatID)
+			if err := m.db.Where("agent_id = ? AND chat_id = ?", agent.ID, chatID).Delete(&store.MessageLink{}).Error; err != nil {
+				return err
+			}
+			_, err := ctx.Sender.Send(ctx.ChatID, "Conversation history cleared.")
+			return err
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "model",
+		Description: "Model configuration (not available from Telegram)",
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Sender.Send(ctx.ChatID, "Model configuration isn't available from Telegram yet.")
+			return err
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "persona",
+		Description: "Persona configuration (not available from Telegram)",
+		Handler: func(ctx *router.Context) error {
+			_, err := ctx.Sender.Send(ctx.ChatID, "Persona configuration isn't available from Telegram yet.")
+			return err
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "enroll2fa",
+		Description: "Enroll yourself in the 2FA required for sensitive admin commands",
+		AdminOnly:   true,
+		Handler: func(ctx *router.Context) error {
+			return m.enrollAdmin(agent.ID, ctx)
+		},
+	})
+
+	reg.Register(&router.Command{
+		Name:        "broadcast",
+		Description: "Send a message to every chat this agent has talked to",
+		AdminOnly:   true,
+		Handler: m.requireTOTP(agent.ID, func(ctx *router.Context) error {
+			if ctx.Args == "" {
+				_, err := ctx.Sender.Send(ctx.ChatID, "Usage: /broadcast <message>")
+				return err
+			}
+			if err := m.broadcast(agent.ID, ctx.Args); err != nil {
+				return err
+			}
+			_, err := ctx.Sender.Send(ctx.ChatID, "Broadcast sent.")
+			return err
+		}),
+	})
+
+	reg.Register(&router.Command{
+		Name:        "reload",
+		Description: "Reload this agent's Telegram config (admins, rate limits, commands)",
+		AdminOnly:   true,
+		Handler: m.requireTOTP(agent.ID, func(ctx *router.Context) error {
+			var fresh store.Agent
+			if err := m.db.Where("id = ?", agent.ID).First(&fresh).Error; err != nil {
+				return err
+			}
+			m.refreshAgentConfig(&fresh)
+			_, err := ctx.Sender.Send(ctx.ChatID, "Config reloaded.")
+			return err
+		}),
+	})
+
+	for _, custom := range customCommandsFromConfig(agent.TelegramConfig) {
+		custom := custom
+		reg.Register(&router.Command{
+			Name:        custom.Name,
+			Description: custom.Description,
+			Handler: func(ctx *router.Context) error {
+				_, err := ctx.Sender.Send(ctx.ChatID, custom.Response)
+				return err
+			},
+		})
+	}
+
+	return reg
+}
+
+func helpText(reg *router.Registry) string {
+	var b strings.Builder
+	b.WriteString("Available commands:\n")
+	for _, cmd := range reg.Commands() {
+		if cmd.AdminOnly {
+			continue
+		}
+		fmt.Fprintf(&b, "/%s - %s\n", cmd.Name, cmd.Description)
+	}
+	return b.String()
+}
+
+func adminIDsFromConfig(cfg map[string]interface{}) []int64 {
+	raw, ok := cfg["admin_ids"].([]interface{})
+	if !ok {
+		return nil
+	}
+	ids := make([]int64, 0, len(raw))
+	for _, v := range raw {
+		if n, err := toInt(v); err == nil {
+			ids = append(ids, int64(n))
+		}
+	}
+	return ids
+}
+
+type customCommand struct {
+	Name        string
+	Description string
+	Response    string
+}
+
+func customCommandsFromConfig(cfg map[string]interface{}) []customCommand {
+	raw, ok := cfg["commands"].([]interface{})
+	if !ok {
+		return nil
+	}
+
+	cmds := make([]customCommand, 0, len(raw))
+	for _, v := range raw {
+		entry, ok := v.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := entry["name"].(string)
+		if name == "" {
+			continue
+		}
+		desc, _ := entry["description"].(string)
+		resp, _ := entry["response"].(string)
+		cmds = append(cmds, customCommand{Name: name, Description: desc, Response: resp})
+	}
+	return cmds
+}
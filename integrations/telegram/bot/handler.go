@@ -4,71 +4,336 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"strings"
+	"time"
 
+	"github.com/crestalnetwork/intentkit/integrations/telegram/api"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/bot/router"
 	"github.com/mymmrac/telego"
 	tu "github.com/mymmrac/telego/telegoutil"
 	"github.com/rs/xid"
 )
 
-func (m *Manager) handleMessage(bot *telego.Bot, message telego.Message, agentID string) {
-    // Basic text filter for now
-    if message.Text == "" {
-        return
-    }
-	    
-		slog.Info("Received message", "agent_id", agentID, "chat_id", message.Chat.ID, "text", message.Text)
-
-        // Show typing action
-        _ = bot.SendChatAction(context.Background(), tu.ChatAction(tu.ID(message.Chat.ID), telego.ChatActionTyping))
-
-		// Prepare payload for Core API
-		// Assuming ChatMessageCreate structure:
-		// agent_id, chat_id, user_id, author_id, author_type, thread_type, message
-		
-		userID := fmt.Sprintf("%d", message.From.ID)
-		if message.From.Username != "" {
-		    // Prefer username if available as per existing logic, or keep ID?
-		    // Existing logic tries to lookup User by TG username. 
-		    // For simplicity here we might just use string ID or username if we want to mimic existing specific logic, 
-		    // but passing raw ID is safer if we don't have User table access/logic here.
-		    // The requirement says "reproduce core logic". 
-		    // app/services/tg/bot/kind/ai_relayer/router.py:get_user_id tries to find user by username.
-		    // We will stick to simple ID for now or username if present to be recognizable.
-		    // However, the safe bet is unique ID. Let's use ID for reliability.
-            if message.From.Username != "" {
-                userID = message.From.Username
-            }
+// streamEditInterval throttles how often we edit the placeholder message
+// while a response is streaming in, to stay well under Telegram's per-chat
+// edit rate limits. maxStreamEditInterval caps the backoff applied when
+// Telegram starts rejecting edits (e.g. a 429), since the agent has already
+// run by then and we'd rather coalesce edits than pay for a second run.
+const (
+	streamEditInterval    = 400 * time.Millisecond
+	maxStreamEditInterval = 10 * time.Second
+)
+
+func (m *Manager) handleMessage(session Session, message IncomingMessage, agentID string) {
+	m.mu.RLock()
+	registry := m.registries[agentID]
+	m.mu.RUnlock()
+
+	if message.IsCallback {
+		m.handleCallback(session, registry, message, agentID)
+		return
+	}
+
+	if m.handlePendingChallenge(session, message, agentID) {
+		return
+	}
+
+	if registry != nil {
+		cmdCtx := &router.Context{
+			AgentID: agentID,
+			ChatID:  message.ChatID,
+			UserID:  message.UserID,
+			Text:    message.Text,
+			Sender:  session,
+		}
+		if handled, err := registry.Dispatch(cmdCtx); handled {
+			if err != nil {
+				slog.Error("Command handler failed", "agent_id", agentID, "error", err)
+			}
+			return
+		}
+	}
+
+	attachments, transcript, err := m.extractAttachments(session, message)
+	if err != nil {
+		slog.Warn("Failed to process attachment", "agent_id", agentID, "error", err)
+	}
+	if transcript != "" && message.Text == "" {
+		message.Text = transcript
+	}
+
+	// Basic text filter for now; attachment-only messages (e.g. a bare photo)
+	// are still forwarded so the agent can react to them.
+	if message.Text == "" && len(attachments) == 0 {
+		return
+	}
+
+	if !m.allowMessage(agentID, message.ChatID, message.UserID) {
+		_, _ = session.Send(message.ChatID, "You're sending messages a bit too fast. Please slow down and try again shortly.")
+		return
+	}
+
+	slog.Info("Received message", "agent_id", agentID, "chat_id", message.ChatID, "text", message.Text, "attachments", len(attachments))
+
+	// Show typing action. Only BotDriver sessions support this today.
+	if bd, ok := session.(*BotDriver); ok {
+		_ = bd.Bot().SendChatAction(context.Background(), tu.ChatAction(tu.ID(message.ChatID), telego.ChatActionTyping))
+	}
+
+	// Prepare payload for Core API
+	// Assuming ChatMessageCreate structure:
+	// agent_id, chat_id, user_id, author_id, author_type, thread_type, message
+
+	userID := fmt.Sprintf("%d", message.UserID)
+	if message.Username != "" {
+		userID = message.Username
+	}
+
+	chatID := chatIDString(message.ChatID)
+
+	// If the user replied to an earlier message, walk that reply chain back
+	// into history so they can branch the conversation instead of only ever
+	// continuing the latest turn.
+	var parentMessageID *int
+	if message.ReplyToID != 0 {
+		parentMessageID = &message.ReplyToID
+	}
+	m.recordMessageLink(agentID, chatID, message.MessageID, parentMessageID, "user", message.Text, "")
+	history := m.buildHistory(agentID, chatID, message.ReplyToID)
+
+	payload := map[string]interface{}{
+		"id":          xid.New().String(),
+		"agent_id":    agentID,
+		"chat_id":     chatID, // Treat simple chat ID as string
+		"user_id":     userID,
+		"author_id":   userID,
+		"author_type": "telegram",
+		"thread_type": "telegram",
+		"message":     message.Text,
+	}
+	if len(history) > 0 {
+		payload["history"] = history
+	}
+	if len(attachments) > 0 {
+		payload["attachments"] = attachments
+	}
+
+	placeholderID, err := session.Send(message.ChatID, "…")
+	if err != nil {
+		slog.Error("Failed to send placeholder message", "agent_id", agentID, "error", err)
+		return
+	}
+
+	var reply strings.Builder
+	var replyAttachments []api.Attachment
+	var lastEditedText string
+	lastEdit := time.Now()
+	editInterval := time.Duration(streamEditInterval)
+
+	streamErr := m.apiClient.ExecuteAgentStream(payload, func(chunk api.ChatMessageDelta) error {
+		reply.WriteString(chunk.Text)
+		if len(chunk.Attachments) > 0 {
+			replyAttachments = append(replyAttachments, chunk.Attachments...)
+		}
+		if !chunk.Done && time.Since(lastEdit) < editInterval {
+			return nil
+		}
+		if reply.Len() == 0 {
+			return nil
+		}
+		// A failed edit (e.g. a Telegram rate limit) is not a reason to abort
+		// the stream: the agent has already produced this reply, so aborting
+		// here would only make handleMessage re-run it from scratch via
+		// sendNonStreamingReply. Back off the edit cadence instead and keep
+		// accumulating; the final edit below catches us up once streaming ends.
+		if err := session.Edit(message.ChatID, placeholderID, reply.String()); err != nil {
+			slog.Warn("Failed to edit streaming reply, backing off", "agent_id", agentID, "error", err)
+			editInterval = minDuration(editInterval*2, maxStreamEditInterval)
+			return nil
+		}
+		lastEdit = time.Now()
+		lastEditedText = reply.String()
+		editInterval = streamEditInterval
+		return nil
+	})
+
+	if streamErr != nil && reply.Len() == 0 {
+		slog.Warn("Core API does not support streaming, falling back to a single response", "agent_id", agentID, "error", streamErr)
+		m.sendNonStreamingReply(session, payload, agentID, chatID, message.ChatID, message.MessageID, placeholderID)
+		return
+	}
+	if streamErr != nil {
+		// The stream broke off after we'd already shown the user part of a
+		// reply (e.g. a network blip mid-stream), not because the endpoint
+		// doesn't support streaming at all. Re-running via
+		// sendNonStreamingReply here would be a second, non-idempotent agent
+		// execution stacked on one that already partially succeeded; keep
+		// what streamed in instead.
+		slog.Warn("Streaming reply broke off after partial content; keeping it instead of re-executing", "agent_id", agentID, "error", streamErr)
+	}
+
+	if reply.Len() > 0 && reply.String() != lastEditedText {
+		if err := session.Edit(message.ChatID, placeholderID, reply.String()); err != nil {
+			slog.Warn("Failed to send final streamed reply", "agent_id", agentID, "error", err)
 		}
+	}
 
-		payload := map[string]interface{}{
-		    "id": xid.New().String(),
-			"agent_id":    agentID,
-			"chat_id":     fmt.Sprintf("%d", message.Chat.ID), // Treat simple chat ID as string
-			"user_id":     userID,
-			"author_id":   userID,
-			"author_type": "telegram",
-			"thread_type": "telegram",
-			"message":     message.Text,
+	m.sendReplyAttachments(session, agentID, chatID, message.ChatID, message.MessageID, convertAPIAttachments(replyAttachments))
+
+	if reply.Len() == 0 {
+		if len(replyAttachments) == 0 {
+			_ = session.Edit(message.ChatID, placeholderID, "Sorry, I couldn't generate a response.")
+		} else {
+			_ = session.Edit(message.ChatID, placeholderID, "…")
+		}
+		return
+	}
+
+	m.recordMessageLink(agentID, chatID, placeholderID, &message.MessageID, "assistant", reply.String(), "")
+}
+
+// handleCallback runs the command behind an inline keyboard button (e.g. the
+// fuzzy-match "Did you mean /help?" suggestion) and acknowledges the
+// CallbackQuery so Telegram stops showing its loading spinner.
+func (m *Manager) handleCallback(session Session, registry *router.Registry, message IncomingMessage, agentID string) {
+	if bd, ok := session.(*BotDriver); ok {
+		_ = bd.AnswerCallback(message.CallbackID)
+	}
+
+	if registry == nil {
+		return
+	}
+
+	cmdCtx := &router.Context{
+		AgentID: agentID,
+		ChatID:  message.ChatID,
+		UserID:  message.UserID,
+		Sender:  session,
+	}
+	if err := registry.DispatchCallback(cmdCtx, message.CallbackData); err != nil {
+		slog.Error("Callback handler failed", "agent_id", agentID, "error", err)
+	}
+}
+
+// sendNonStreamingReply is the fallback path for Core API deployments that
+// don't advertise streaming: it reproduces the old single-shot behavior,
+// finishing off the placeholder message with the first reply.
+func (m *Manager) sendNonStreamingReply(session Session, payload map[string]interface{}, agentID, chatID string, rawChatID int64, userMessageID, placeholderID int) {
+	resp, err := m.apiClient.ExecuteAgent(payload)
+	if err != nil {
+		slog.Error("Failed to execute agent", "error", err)
+		_ = session.Edit(rawChatID, placeholderID, "Sorry, I encountered an error processing your request.")
+		return
+	}
+
+	var texts []string
+	var replyAttachments []Attachment
+	for _, msg := range resp {
+		if msgMap, ok := msg.(map[string]interface{}); ok {
+			if text, ok := msgMap["message"].(string); ok && text != "" {
+				texts = append(texts, text)
+			}
+			replyAttachments = append(replyAttachments, attachmentsFromResponse(msgMap)...)
 		}
+	}
+
+	if len(texts) == 0 && len(replyAttachments) == 0 {
+		_ = session.Edit(rawChatID, placeholderID, "Sorry, I couldn't generate a response.")
+		return
+	}
 
-		// Call Core API
-		resp, err := m.apiClient.ExecuteAgent(payload)
+	if len(texts) == 0 {
+		_ = session.Edit(rawChatID, placeholderID, "…")
+	} else {
+		_ = session.Edit(rawChatID, placeholderID, texts[0])
+		m.recordMessageLink(agentID, chatID, placeholderID, &userMessageID, "assistant", texts[0], "")
+		texts = texts[1:]
+	}
+
+	for _, text := range texts {
+		sentID, err := session.Send(rawChatID, text)
 		if err != nil {
-			slog.Error("Failed to execute agent", "error", err)
-			_, _ = bot.SendMessage(context.Background(), tu.Message(tu.ID(message.Chat.ID), "Sorry, I encountered an error processing your request."))
-			return
+			continue
 		}
+		m.recordMessageLink(agentID, chatID, sentID, &userMessageID, "assistant", text, "")
+	}
 
-        // Process response
-        // Expecting list of messages. We typically want the last one or all new ones.
-        // Core API returns list[ChatMessage].
-        if len(resp) > 0 {
-            for _, msg := range resp {
-                if msgMap, ok := msg.(map[string]interface{}); ok {
-                    if text, ok := msgMap["message"].(string); ok && text != ""{
-                         _, _ = bot.SendMessage(context.Background(), tu.Message(tu.ID(message.Chat.ID), text))
-                    }
-                }
-            }
-        }
+	m.sendReplyAttachments(session, agentID, chatID, rawChatID, userMessageID, replyAttachments)
+}
+
+// minDuration returns the smaller of a and b, used to cap the streaming edit
+// backoff at maxStreamEditInterval.
+func minDuration(a, b time.Duration) time.Duration {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// convertAPIAttachments adapts the streaming client's Attachment type to the
+// one sendReplyAttachments expects, which also carries inbound attachment
+// data (Data) that a streamed reply never has.
+func convertAPIAttachments(in []api.Attachment) []Attachment {
+	if len(in) == 0 {
+		return nil
+	}
+	out := make([]Attachment, len(in))
+	for i, a := range in {
+		out[i] = Attachment{Type: a.Type, Mime: a.Mime, URL: a.URL}
+	}
+	return out
+}
+
+// attachmentsFromResponse pulls any "attachments" entries out of a Core API
+// response message, in the same {type, mime, url} shape the Telegram
+// integration forwards on the way in.
+func attachmentsFromResponse(msgMap map[string]interface{}) []Attachment {
+	raw, ok := msgMap["attachments"].([]interface{})
+	if !ok {
+		return nil
+	}
+	attachments := make([]Attachment, 0, len(raw))
+	for _, item := range raw {
+		entry, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		a := Attachment{}
+		a.Type, _ = entry["type"].(string)
+		a.Mime, _ = entry["mime"].(string)
+		a.URL, _ = entry["url"].(string)
+		if a.Type != "" && a.URL != "" {
+			attachments = append(attachments, a)
+		}
+	}
+	return attachments
+}
+
+// sendReplyAttachments forwards image/audio attachments from a Core API
+// response as native Telegram photo/voice messages. Only BotDriver sessions
+// can send these today; other attachment types and non-bot sessions are
+// skipped.
+func (m *Manager) sendReplyAttachments(session Session, agentID, chatID string, rawChatID int64, userMessageID int, attachments []Attachment) {
+	bd, ok := session.(*BotDriver)
+	if !ok {
+		return
+	}
+
+	for _, a := range attachments {
+		var sentID int
+		var err error
+		switch a.Type {
+		case "image":
+			sentID, err = bd.SendPhotoURL(rawChatID, a.URL, "")
+		case "audio":
+			sentID, err = bd.SendVoiceURL(rawChatID, a.URL, "")
+		default:
+			continue
+		}
+		if err != nil {
+			slog.Warn("Failed to send reply attachment", "agent_id", agentID, "type", a.Type, "error", err)
+			continue
+		}
+		m.recordMessageLink(agentID, chatID, sentID, &userMessageID, "assistant", "", "")
+	}
 }
@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
+)
+
+// maxHistoryDepth bounds how far back we'll walk a reply chain, so a very
+// long-lived thread can't make a single request reconstruct unbounded history.
+const maxHistoryDepth = 20
+
+// recordMessageLink persists one turn of a conversation so later replies can
+// walk the chain back into history.
+func (m *Manager) recordMessageLink(agentID, chatID string, messageID int, parentMessageID *int, role, text, coreMessageID string) {
+	if messageID == 0 {
+		return
+	}
+	link := store.MessageLink{
+		AgentID:         agentID,
+		ChatID:          chatID,
+		MessageID:       messageID,
+		ParentMessageID: parentMessageID,
+		Role:            role,
+		Text:            text,
+		CoreMessageID:   coreMessageID,
+	}
+	if err := m.db.Create(&link).Error; err != nil {
+		slog.Error("Failed to persist message link", "agent_id", agentID, "error", err)
+	}
+}
+
+// buildHistory walks the reply chain starting at replyToID and returns it as
+// oldest-first turns suitable for the Core API's `history` field.
+func (m *Manager) buildHistory(agentID, chatID string, replyToID int) []map[string]interface{} {
+	var history []map[string]interface{}
+
+	current := replyToID
+	for depth := 0; current != 0 && depth < maxHistoryDepth; depth++ {
+		var link store.MessageLink
+		err := m.db.Where("agent_id = ? AND chat_id = ? AND message_id = ?", agentID, chatID, current).First(&link).Error
+		if err != nil {
+			break
+		}
+
+		history = append([]map[string]interface{}{{
+			"role": link.Role,
+			"text": link.Text,
+		}}, history...)
+
+		if link.ParentMessageID == nil {
+			break
+		}
+		current = *link.ParentMessageID
+	}
+
+	return history
+}
+
+// pruneHistory removes message_links older than TgHistoryRetentionDays. It is
+// run once at startup and then on a daily tick alongside syncBots.
+func (m *Manager) pruneHistory() {
+	if m.cfg.TgHistoryRetentionDays <= 0 {
+		return
+	}
+
+	cutoff := time.Now().AddDate(0, 0, -m.cfg.TgHistoryRetentionDays)
+	result := m.db.Where("created_at < ?", cutoff).Delete(&store.MessageLink{})
+	if result.Error != nil {
+		slog.Error("Failed to prune telegram message history", "error", result.Error)
+		return
+	}
+	if result.RowsAffected > 0 {
+		slog.Info("Pruned telegram message history", "rows", result.RowsAffected, "cutoff", cutoff)
+	}
+}
+
+func chatIDString(chatID int64) string {
+	return fmt.Sprintf("%d", chatID)
+}
@@ -8,44 +8,71 @@ import (
 	"time"
 
 	"github.com/crestalnetwork/intentkit/integrations/telegram/api"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/bot/router"
 	"github.com/crestalnetwork/intentkit/integrations/telegram/config"
 	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
-	"github.com/mymmrac/telego"
 	"gorm.io/gorm"
 )
 
 type Manager struct {
-	db         *gorm.DB
-	cfg        *config.Config
-	apiClient  *api.Client
-	bots       map[string]*telego.Bot
-	cancelFuncs map[string]context.CancelFunc
-	mu         sync.RWMutex
-	stopCh     chan struct{}
+	db                *gorm.DB
+	cfg               *config.Config
+	apiClient         *api.Client
+	sessions          map[string]Session
+	registries        map[string]*router.Registry
+	cancelFuncs       map[string]context.CancelFunc
+	webhook           *webhookServer
+	transcriber       Transcriber
+	rateLimiters      map[string]*rateLimiter
+	pendingChallenges map[string]*pendingChallenge
+	mu                sync.RWMutex
+	stopCh            chan struct{}
 }
 
 func NewManager(db *gorm.DB, cfg *config.Config, apiClient *api.Client) *Manager {
-	return &Manager{
-		db:          db,
-		cfg:         cfg,
-		apiClient:   apiClient,
-		bots:        make(map[string]*telego.Bot),
-		cancelFuncs: make(map[string]context.CancelFunc),
-		stopCh:      make(chan struct{}),
+	m := &Manager{
+		db:                db,
+		cfg:               cfg,
+		apiClient:         apiClient,
+		sessions:          make(map[string]Session),
+		registries:        make(map[string]*router.Registry),
+		cancelFuncs:       make(map[string]context.CancelFunc),
+		rateLimiters:      make(map[string]*rateLimiter),
+		pendingChallenges: make(map[string]*pendingChallenge),
+		stopCh:            make(chan struct{}),
 	}
+
+	if cfg.TgMode == "webhook" {
+		m.webhook = newWebhookServer(cfg.TgWebhookListenAddr)
+	}
+	if cfg.OpenAIAPIKey != "" {
+		m.transcriber = NewOpenAIWhisperTranscriber(cfg.OpenAIAPIKey)
+	}
+
+	return m
 }
 
 func (m *Manager) Start() {
+	if m.webhook != nil {
+		m.webhook.start()
+	}
+
 	ticker := time.NewTicker(time.Duration(m.cfg.TgNewAgentPollInterval) * time.Second)
 	defer ticker.Stop()
 
+	pruneTicker := time.NewTicker(24 * time.Hour)
+	defer pruneTicker.Stop()
+
 	// Initial sync
 	m.syncBots()
+	m.pruneHistory()
 
 	for {
 		select {
 		case <-ticker.C:
 			m.syncBots()
+		case <-pruneTicker.C:
+			m.pruneHistory()
 		case <-m.stopCh:
 			return
 		}
@@ -55,12 +82,15 @@ func (m *Manager) Start() {
 func (m *Manager) Stop() {
 	close(m.stopCh)
 	m.mu.Lock()
-	defer m.mu.Unlock()
-
 	for id, cancel := range m.cancelFuncs {
 		cancel()
 		slog.Info("Stopped bot", "agent_id", id)
 	}
+	m.mu.Unlock()
+
+	if m.webhook != nil {
+		m.webhook.stop(context.Background())
+	}
 }
 
 func (m *Manager) syncBots() {
@@ -75,6 +105,7 @@ func (m *Manager) syncBots() {
 	for _, agent := range agents {
 		activeAgentIDs[agent.ID] = true
 		m.ensureBotRunning(&agent)
+		m.refreshAgentConfig(&agent)
 	}
 
 	// Stop bots for disabled/removed agents
@@ -82,8 +113,10 @@ func (m *Manager) syncBots() {
 	for id, cancel := range m.cancelFuncs {
 		if !activeAgentIDs[id] {
 			cancel()
-			delete(m.bots, id)
+			delete(m.sessions, id)
+			delete(m.registries, id)
 			delete(m.cancelFuncs, id)
+			delete(m.rateLimiters, id)
 			slog.Info("Stopped and removed bot for agent", "agent_id", id)
 		}
 	}
@@ -92,77 +125,95 @@ func (m *Manager) syncBots() {
 
 func (m *Manager) ensureBotRunning(agent *store.Agent) {
 	m.mu.RLock()
-	_, exists := m.bots[agent.ID]
+	_, exists := m.sessions[agent.ID]
 	m.mu.RUnlock()
 
 	if exists {
-		// potential updates check could go here, for now assuming if it's running it's fine
-		// untill config changes which we might need to track
+		// Already running; refreshAgentConfig (called right after us on every
+		// syncBots tick) picks up TelegramConfig changes like admins/rate
+		// limits without needing a restart.
 		return
 	}
 
-	token := getTokenFromConfig(agent.TelegramConfig)
-	if token == "" {
-		slog.Warn("Agent has enabled telegram but no valid token", "agent_id", agent.ID)
+	session, err := m.newSession(agent)
+	if err != nil {
+		slog.Warn("Failed to build telegram session for agent", "agent_id", agent.ID, "error", err)
 		return
 	}
 
-	bot, err := telego.NewBot(token, telego.WithDefaultDebugLogger())
-	if err != nil {
-		slog.Error("Failed to create bot", "agent_id", agent.ID, "error", err)
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := session.Start(ctx); err != nil {
+		slog.Error("Failed to start telegram session", "agent_id", agent.ID, "error", err)
+		cancel()
 		return
 	}
 
 	// Update AgentData on first run
-	if err := m.updateAgentData(agent.ID, bot); err != nil {
+	if err := m.updateAgentData(agent.ID, session); err != nil {
 		slog.Error("Failed to update agent data", "agent_id", agent.ID, "error", err)
 	}
 
-	// Start Long Polling
-	ctx, cancel := context.WithCancel(context.Background())
-	updates, err := bot.UpdatesViaLongPolling(ctx, nil)
-	if err != nil {
-		slog.Error("Failed to get updates channel", "agent_id", agent.ID, "error", err)
-		cancel()
-		return
+	registry := m.buildRegistry(agent)
+	if bd, ok := session.(*BotDriver); ok {
+		if err := bd.SetCommands(registry.Commands()); err != nil {
+			slog.Warn("Failed to set command menu", "agent_id", agent.ID, "error", err)
+		}
 	}
-	
+
 	go func() {
-		for update := range updates {
-			if update.Message != nil {
-				m.handleMessage(bot, *update.Message, agent.ID)
-			}
+		for msg := range session.Updates() {
+			m.handleMessage(session, msg, agent.ID)
 		}
 	}()
 
 	m.mu.Lock()
-	m.bots[agent.ID] = bot
-	m.cancelFuncs[agent.ID] = cancel
+	m.sessions[agent.ID] = session
+	m.registries[agent.ID] = registry
+	m.cancelFuncs[agent.ID] = func() {
+		cancel()
+		_ = session.Stop()
+	}
 	m.mu.Unlock()
 
-	slog.Info("Started bot for agent", "agent_id", agent.ID)
+	slog.Info("Started telegram session for agent", "agent_id", agent.ID, "mode", telegramMode(agent))
+}
+
+// newSession picks the right driver for the agent based on
+// TelegramConfig["mode"]: "user" for an MTProto user account, anything else
+// (the default) for a classic bot.
+func (m *Manager) newSession(agent *store.Agent) (Session, error) {
+	if telegramMode(agent) == "user" {
+		return newUserDriver(m.db, m.cfg, agent)
+	}
+
+	token := getTokenFromConfig(agent.TelegramConfig)
+	if token == "" {
+		return nil, fmt.Errorf("agent has no telegram bot token configured")
+	}
+	return newBotDriver(agent.ID, token, m.cfg, m.webhook)
 }
 
-func (m *Manager) updateAgentData(agentID string, bot *telego.Bot) error {
-	me, err := bot.GetMe(context.Background())
+func telegramMode(agent *store.Agent) string {
+	if mode, ok := agent.TelegramConfig["mode"].(string); ok && mode != "" {
+		return mode
+	}
+	return "bot"
+}
+
+func (m *Manager) updateAgentData(agentID string, session Session) error {
+	id, username, fullName, err := session.WhoAmI(context.Background())
 	if err != nil {
 		return err
 	}
 
-	username := me.Username
-	fullName := me.FirstName + " " + me.LastName
-    if me.LastName == "" {
-        fullName = me.FirstName
-    }
-    
-    idStr := fmt.Sprintf("%d", me.ID)
+	idStr := fmt.Sprintf("%d", id)
 
 	// Upsert AgentData
-    // We use FirstOrCreate to ensure the record exists, then Update to set values
-    var agentData store.AgentData
-    if err := m.db.FirstOrCreate(&agentData, store.AgentData{ID: agentID}).Error; err != nil {
-        return err
-    }
+	// We use FirstOrCreate to ensure the record exists, then Update to set values
+	var agentData store.AgentData
+	if err := m.db.FirstOrCreate(&agentData, store.AgentData{ID: agentID}).Error; err != nil {
+		return err
+	}
 
 	return m.db.Model(&store.AgentData{}).Where("id = ?", agentID).Updates(map[string]interface{}{
 		"telegram_id":       idStr,
@@ -0,0 +1,145 @@
+package bot
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/config"
+)
+
+// rateLimitConfig is how often (per minute) and how bursty one agent lets a
+// single chat+user pair message it before throttling.
+type rateLimitConfig struct {
+	perMinute float64
+	burst     int
+}
+
+// tokenBucket is a classic token bucket: it refills at perMinute/60 tokens
+// per second, up to burst, and each request spends one token.
+type tokenBucket struct {
+	tokens     float64
+	perSecond  float64
+	burst      float64
+	lastRefill time.Time
+}
+
+func newTokenBucket(cfg rateLimitConfig) *tokenBucket {
+	return &tokenBucket{
+		tokens:     float64(cfg.burst),
+		perSecond:  cfg.perMinute / 60,
+		burst:      float64(cfg.burst),
+		lastRefill: time.Now(),
+	}
+}
+
+func (b *tokenBucket) allow() bool {
+	now := time.Now()
+	b.tokens += now.Sub(b.lastRefill).Seconds() * b.perSecond
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.lastRefill = now
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// rateLimiter enforces a per-agent token bucket keyed by "chatID:userID", so
+// one noisy chat can't starve another on the same agent.
+type rateLimiter struct {
+	mu      sync.Mutex
+	cfg     rateLimitConfig
+	buckets map[string]*tokenBucket
+}
+
+func newRateLimiter(cfg rateLimitConfig) *rateLimiter {
+	return &rateLimiter{cfg: cfg, buckets: make(map[string]*tokenBucket)}
+}
+
+func (r *rateLimiter) setConfig(cfg rateLimitConfig) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cfg = cfg
+	for _, b := range r.buckets {
+		b.perSecond = cfg.perMinute / 60
+		b.burst = float64(cfg.burst)
+		if b.tokens > b.burst {
+			b.tokens = b.burst
+		}
+	}
+}
+
+func (r *rateLimiter) allow(chatID int64, userID int64) bool {
+	key := fmt.Sprintf("%d:%d", chatID, userID)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	b, ok := r.buckets[key]
+	if !ok {
+		b = newTokenBucket(r.cfg)
+		r.buckets[key] = b
+	}
+	return b.allow()
+}
+
+// allowMessage checks agent_id:chat_id:user_id against that agent's
+// configured rate limit. An agent whose limiter setRateLimit hasn't
+// installed yet is not limited.
+func (m *Manager) allowMessage(agentID string, chatID, userID int64) bool {
+	m.mu.RLock()
+	limiter := m.rateLimiters[agentID]
+	m.mu.RUnlock()
+
+	if limiter == nil {
+		return true
+	}
+	return limiter.allow(chatID, userID)
+}
+
+// setRateLimit installs or updates the rate limiter for an agent, called on
+// every syncBots tick so TelegramConfig changes take effect without a
+// restart.
+func (m *Manager) setRateLimit(agentID string, cfg rateLimitConfig) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if limiter, ok := m.rateLimiters[agentID]; ok {
+		limiter.setConfig(cfg)
+		return
+	}
+	m.rateLimiters[agentID] = newRateLimiter(cfg)
+}
+
+// rateLimitConfigFromAgent reads rate_limit_per_minute / rate_limit_burst
+// from TelegramConfig, falling back to the deployment-wide defaults.
+func rateLimitConfigFromAgent(telegramConfig map[string]interface{}, cfg *config.Config) rateLimitConfig {
+	rc := rateLimitConfig{perMinute: float64(cfg.TgDefaultRateLimitPerMinute), burst: cfg.TgDefaultRateLimitBurst}
+
+	if v, ok := telegramConfig["rate_limit_per_minute"]; ok {
+		if n, err := toFloat(v); err == nil && n > 0 {
+			rc.perMinute = n
+		}
+	}
+	if v, ok := telegramConfig["rate_limit_burst"]; ok {
+		if n, err := toInt(v); err == nil && n > 0 {
+			rc.burst = n
+		}
+	}
+	return rc
+}
+
+func toFloat(v interface{}) (float64, error) {
+	switch n := v.(type) {
+	case float64:
+		return n, nil
+	case int:
+		return float64(n), nil
+	default:
+		return 0, fmt.Errorf("unsupported type %T", v)
+	}
+}
@@ -0,0 +1,101 @@
+package router
+
+import (
+	"sort"
+	"strings"
+)
+
+// score ranks candidate against query by restricted (optimal string
+// alignment) edit distance: insertions, deletions, substitutions, and
+// transpositions of two adjacent characters each cost 1. Unlike a plain
+// in-order-subsequence match, this also catches the typo a "did you mean"
+// suggestion exists for in the first place — e.g. "hlep" vs "help", a
+// transposition a subsequence check can't see since "hlep" doesn't contain
+// "help"'s letters in order. Lower distance is a better match; the returned
+// score inverts that, so a higher score is a better match.
+func score(query, candidate string) int {
+	q := []rune(strings.ToLower(query))
+	c := []rune(strings.ToLower(candidate))
+
+	maxLen := len(q)
+	if len(c) > maxLen {
+		maxLen = len(c)
+	}
+	if maxLen == 0 {
+		return 0
+	}
+
+	return maxLen - 2*editDistance(q, c)
+}
+
+// editDistance computes the restricted Damerau-Levenshtein distance between
+// a and b.
+func editDistance(a, b []rune) int {
+	d := make([][]int, len(a)+1)
+	for i := range d {
+		d[i] = make([]int, len(b)+1)
+		d[i][0] = i
+	}
+	for j := 0; j <= len(b); j++ {
+		d[0][j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			d[i][j] = min3(d[i-1][j]+1, d[i][j-1]+1, d[i-1][j-1]+cost)
+			if i > 1 && j > 1 && a[i-1] == b[j-2] && a[i-2] == b[j-1] {
+				if transposed := d[i-2][j-2] + 1; transposed < d[i][j] {
+					d[i][j] = transposed
+				}
+			}
+		}
+	}
+	return d[len(a)][len(b)]
+}
+
+func min3(a, b, c int) int {
+	if b < a {
+		a = b
+	}
+	if c < a {
+		a = c
+	}
+	return a
+}
+
+type fuzzyMatch struct {
+	name  string
+	score int
+}
+
+// topMatches returns up to k candidate names scoring at or above threshold,
+// best match first.
+func topMatches(query string, candidates []string, k, threshold int) []string {
+	matches := make([]fuzzyMatch, 0, len(candidates))
+	for _, candidate := range candidates {
+		if s := score(query, candidate); s >= threshold {
+			matches = append(matches, fuzzyMatch{name: candidate, score: s})
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].score != matches[j].score {
+			return matches[i].score > matches[j].score
+		}
+		return matches[i].name < matches[j].name
+	})
+
+	if len(matches) > k {
+		matches = matches[:k]
+	}
+
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = m.name
+	}
+	return names
+}
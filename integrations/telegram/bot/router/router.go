@@ -0,0 +1,182 @@
+// Package router dispatches Telegram slash commands and inline keyboard
+// callbacks before a message falls through to LLM execution. It has no
+// dependency on the bot package so it can be unit tested in isolation; the
+// bot package adapts its Session type to the Sender interface instead.
+package router
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	fuzzyTopK      = 3
+	fuzzyThreshold = 1
+)
+
+// Sender is the minimal capability a command handler needs: posting a
+// plain text reply. bot.Session satisfies this today.
+type Sender interface {
+	Send(chatID int64, text string) (int, error)
+}
+
+// KeyboardSender is implemented by drivers that can attach inline keyboards
+// to a message (currently only the Bot API). Registry falls back to a plain
+// text suggestion when the active session doesn't support it.
+type KeyboardSender interface {
+	SendWithKeyboard(chatID int64, text string, buttons []Button) (int, error)
+}
+
+// Button is one inline keyboard button; Data is echoed back as the
+// CallbackQuery data when pressed.
+type Button struct {
+	Text string
+	Data string
+}
+
+// Context carries everything a Command handler needs about the message or
+// callback that triggered it.
+type Context struct {
+	AgentID string
+	ChatID  int64
+	UserID  int64
+	Text    string
+	Args    string
+	Sender  Sender
+}
+
+// Command is one registered slash command.
+type Command struct {
+	Name        string
+	Description string
+	AdminOnly   bool
+	Handler     func(ctx *Context) error
+}
+
+// Registry holds the slash commands available to one agent and dispatches
+// incoming text/callbacks to them.
+type Registry struct {
+	commands map[string]*Command
+	adminIDs map[int64]bool
+}
+
+func NewRegistry() *Registry {
+	return &Registry{commands: make(map[string]*Command)}
+}
+
+// Register adds or replaces a command.
+func (r *Registry) Register(cmd *Command) {
+	r.commands[strings.ToLower(cmd.Name)] = cmd
+}
+
+// SetAdmins replaces the allow-list of Telegram user IDs permitted to run
+// admin-only commands.
+func (r *Registry) SetAdmins(ids []int64) {
+	admins := make(map[int64]bool, len(ids))
+	for _, id := range ids {
+		admins[id] = true
+	}
+	r.adminIDs = admins
+}
+
+func (r *Registry) IsAdmin(userID int64) bool {
+	return r.adminIDs[userID]
+}
+
+// Commands returns every registered command, in no particular order.
+func (r *Registry) Commands() []*Command {
+	cmds := make([]*Command, 0, len(r.commands))
+	for _, cmd := range r.commands {
+		cmds = append(cmds, cmd)
+	}
+	return cmds
+}
+
+// Dispatch handles a message if it starts with "/". handled is false when
+// the text isn't a command at all, so the caller should fall through to LLM
+// execution.
+func (r *Registry) Dispatch(ctx *Context) (handled bool, err error) {
+	name, args, ok := parseCommand(ctx.Text)
+	if !ok {
+		return false, nil
+	}
+	ctx.Args = args
+
+	cmd, exists := r.commands[name]
+	if !exists {
+		r.suggest(ctx, name)
+		return true, nil
+	}
+
+	if cmd.AdminOnly && !r.IsAdmin(ctx.UserID) {
+		_, _ = ctx.Sender.Send(ctx.ChatID, "This command is for admins only.")
+		return true, nil
+	}
+
+	return true, cmd.Handler(ctx)
+}
+
+// DispatchCallback runs the command named by an inline keyboard button's
+// callback data (as produced by suggest below).
+func (r *Registry) DispatchCallback(ctx *Context, data string) error {
+	name, ok := strings.CutPrefix(data, "cmd:")
+	if !ok {
+		return nil
+	}
+
+	cmd, exists := r.commands[name]
+	if !exists {
+		return nil
+	}
+	if cmd.AdminOnly && !r.IsAdmin(ctx.UserID) {
+		_, _ = ctx.Sender.Send(ctx.ChatID, "This command is for admins only.")
+		return nil
+	}
+	return cmd.Handler(ctx)
+}
+
+func (r *Registry) suggest(ctx *Context, mistyped string) {
+	names := topMatches(mistyped, r.names(), fuzzyTopK, fuzzyThreshold)
+	if len(names) == 0 {
+		_, _ = ctx.Sender.Send(ctx.ChatID, "Unknown command. Use /help to see what's available.")
+		return
+	}
+
+	if ks, ok := ctx.Sender.(KeyboardSender); ok {
+		buttons := make([]Button, len(names))
+		for i, n := range names {
+			buttons[i] = Button{Text: "/" + n, Data: "cmd:" + n}
+		}
+		_, _ = ks.SendWithKeyboard(ctx.ChatID, fmt.Sprintf("Did you mean /%s?", names[0]), buttons)
+		return
+	}
+
+	_, _ = ctx.Sender.Send(ctx.ChatID, fmt.Sprintf("Did you mean /%s?", names[0]))
+}
+
+func (r *Registry) names() []string {
+	names := make([]string, 0, len(r.commands))
+	for name := range r.commands {
+		names = append(names, name)
+	}
+	return names
+}
+
+func parseCommand(text string) (name, args string, ok bool) {
+	if !strings.HasPrefix(text, "/") {
+		return "", "", false
+	}
+
+	text = strings.TrimPrefix(text, "/")
+	parts := strings.SplitN(text, " ", 2)
+	name = strings.ToLower(parts[0])
+	// Telegram clients in group chats append "@botusername" to commands.
+	if at := strings.Index(name, "@"); at != -1 {
+		name = name[:at]
+	}
+	if len(parts) > 1 {
+		args = strings.TrimSpace(parts[1])
+	}
+	return name, args, true
+}
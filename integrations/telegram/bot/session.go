@@ -0,0 +1,54 @@
+package bot
+
+import (
+	"context"
+
+	"github.com/mymmrac/telego"
+)
+
+// Session abstracts a running Telegram identity for one agent, whether it is
+// backed by the classic Bot API (BotDriver) or an MTProto user account
+// (UserDriver). ensureBotRunning picks a driver per agent and handleMessage
+// is written against this interface, so the same code path serves both;
+// bot-only extras (typing indicators, attachments, callback answers) are
+// reached through a *BotDriver type check.
+type Session interface {
+	// Start connects the session and begins delivering updates on Updates().
+	Start(ctx context.Context) error
+	// Stop disconnects the session and releases any resources it holds
+	// (webhook registration, MTProto connection, ...).
+	Stop() error
+	// Send posts a plain text message to the given chat and returns the sent
+	// message's ID so it can later be updated via Edit.
+	Send(chatID int64, text string) (int, error)
+	// Edit replaces the text of a message previously returned by Send, used
+	// to reveal streamed responses incrementally.
+	Edit(chatID int64, messageID int, text string) error
+	// Updates streams incoming messages in a driver-agnostic shape.
+	Updates() <-chan IncomingMessage
+	// WhoAmI returns the identity of the underlying account, used to keep
+	// AgentData in sync.
+	WhoAmI(ctx context.Context) (id int64, username, fullName string, err error)
+}
+
+// IncomingMessage is the driver-agnostic shape handleMessage works with.
+type IncomingMessage struct {
+	ChatID    int64
+	UserID    int64
+	Username  string
+	Text      string
+	MessageID int
+	ReplyToID int
+
+	// IsCallback marks an IncomingMessage built from an inline keyboard
+	// CallbackQuery rather than a regular text message. CallbackData and
+	// CallbackID are only populated in that case.
+	IsCallback   bool
+	CallbackData string
+	CallbackID   string
+
+	// Message carries the original telego message for BotDriver sessions so
+	// bot-only features (currently attachment download) can still get at the
+	// raw payload. It is nil for UserDriver sessions and for callbacks.
+	Message *telego.Message
+}
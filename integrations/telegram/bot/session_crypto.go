@@ -0,0 +1,93 @@
+package bot
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// encryptSecret seals data with AES-256-GCM under keyStr (hex or base64,
+// must decode to 32 bytes) and returns a base64 blob suitable for storing in
+// agent_sessions.encrypted_session or agent_admins.encrypted_totp_seed.
+func encryptSecret(keyStr string, data []byte) (string, error) {
+	block, err := newSecretCipher(keyStr)
+	if err != nil {
+		return "", err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return "", fmt.Errorf("failed to init AES-GCM: %w", err)
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	sealed := gcm.Seal(nonce, nonce, data, nil)
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// decryptSecret reverses encryptSecret.
+func decryptSecret(keyStr, encoded string) ([]byte, error) {
+	block, err := newSecretCipher(keyStr)
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init AES-GCM: %w", err)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode encrypted blob: %w", err)
+	}
+	if len(sealed) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted blob too short")
+	}
+
+	nonce, ciphertext := sealed[:gcm.NonceSize()], sealed[gcm.NonceSize():]
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func newSecretCipher(keyStr string) (cipher.Block, error) {
+	key, err := decodeSessionKey(keyStr)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to init AES cipher: %w", err)
+	}
+	return block, nil
+}
+
+func decodeSessionKey(keyStr string) ([]byte, error) {
+	if keyStr == "" {
+		return nil, fmt.Errorf("TG_SESSION_ENCRYPTION_KEY is not configured")
+	}
+	if key, err := hex.DecodeString(keyStr); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	if key, err := base64.StdEncoding.DecodeString(keyStr); err == nil && len(key) == 32 {
+		return key, nil
+	}
+	return nil, fmt.Errorf("TG_SESSION_ENCRYPTION_KEY must decode to 32 bytes (hex or base64)")
+}
+
+// decodeSession/encodeSession move raw MTProto session bytes in and out of
+// the base64 form the driver's config/credential plumbing works with.
+func decodeSession(b64 string) ([]byte, error) {
+	return base64.StdEncoding.DecodeString(b64)
+}
+
+func encodeSession(data []byte) string {
+	return base64.StdEncoding.EncodeToString(data)
+}
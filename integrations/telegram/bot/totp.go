@@ -0,0 +1,85 @@
+package bot
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// totpStep and totpDigits follow RFC 6238's usual defaults (30s windows,
+// 6-digit codes), matching what every common authenticator app expects.
+const (
+	totpStep   = 30 * time.Second
+	totpDigits = 6
+	// totpSkew allows the code from one step before or after the current one,
+	// to tolerate clock drift between the admin's device and this server.
+	totpSkew = 1
+)
+
+// generateTOTPSecret creates a new random base32-encoded seed suitable for
+// enrolling an admin in an authenticator app.
+func generateTOTPSecret() (string, error) {
+	raw := make([]byte, 20)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate TOTP secret: %w", err)
+	}
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
+
+// totpProvisioningURI builds an otpauth:// URI an authenticator app can
+// import directly (by link or QR code), labeled with the agent and admin so
+// it doesn't collide with other agents' entries in the same app.
+func totpProvisioningURI(agentID string, userID int64, seed string) string {
+	label := fmt.Sprintf("IntentKit:%s-admin-%d", agentID, userID)
+	return fmt.Sprintf("otpauth://totp/%s?secret=%s&issuer=IntentKit&digits=%d&period=%d",
+		url.QueryEscape(label), seed, totpDigits, int(totpStep.Seconds()))
+}
+
+// validateTOTPCode checks a 6-digit code against secret for the current time
+// step, allowing totpSkew steps of drift in either direction.
+func validateTOTPCode(secret, code string) bool {
+	code = strings.TrimSpace(code)
+	if len(code) != totpDigits {
+		return false
+	}
+
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.ToUpper(secret))
+	if err != nil {
+		return false
+	}
+
+	now := time.Now().Unix() / int64(totpStep.Seconds())
+	for skew := -totpSkew; skew <= totpSkew; skew++ {
+		if totpCode(key, now+int64(skew)) == code {
+			return true
+		}
+	}
+	return false
+}
+
+func totpCode(key []byte, counter int64) string {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], uint64(counter))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(buf[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	truncated := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", totpDigits, truncated%pow10(totpDigits))
+}
+
+func pow10(n int) uint32 {
+	result := uint32(1)
+	for i := 0; i < n; i++ {
+		result *= 10
+	}
+	return result
+}
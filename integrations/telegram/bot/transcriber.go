@@ -0,0 +1,82 @@
+package bot
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+)
+
+// Transcriber turns a voice/audio clip into text. OpenAIWhisperTranscriber
+// is the default; other providers can be plugged in by implementing this
+// interface.
+type Transcriber interface {
+	Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error)
+}
+
+// OpenAIWhisperTranscriber transcribes audio via OpenAI's Whisper endpoint.
+type OpenAIWhisperTranscriber struct {
+	apiKey string
+	client *http.Client
+}
+
+func NewOpenAIWhisperTranscriber(apiKey string) *OpenAIWhisperTranscriber {
+	return &OpenAIWhisperTranscriber{apiKey: apiKey, client: http.DefaultClient}
+}
+
+func (t *OpenAIWhisperTranscriber) Transcribe(ctx context.Context, audio []byte, mimeType string) (string, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	part, err := writer.CreateFormFile("file", "audio"+extensionForMime(mimeType))
+	if err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	if _, err := part.Write(audio); err != nil {
+		return "", fmt.Errorf("failed to write audio payload: %w", err)
+	}
+	if err := writer.WriteField("model", "whisper-1"); err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "https://api.openai.com/v1/audio/transcriptions", &body)
+	if err != nil {
+		return "", fmt.Errorf("failed to build transcription request: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+t.apiKey)
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to call whisper: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("whisper returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Text string `json:"text"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", fmt.Errorf("failed to decode whisper response: %w", err)
+	}
+	return result.Text, nil
+}
+
+func extensionForMime(mimeType string) string {
+	switch mimeType {
+	case "audio/mpeg":
+		return ".mp3"
+	case "audio/wav", "audio/x-wav":
+		return ".wav"
+	default:
+		return ".ogg"
+	}
+}
@@ -0,0 +1,379 @@
+package bot
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/crestalnetwork/intentkit/integrations/telegram/config"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
+	"github.com/gotd/td/session"
+	"github.com/gotd/td/telegram"
+	"github.com/gotd/td/telegram/updates"
+	"github.com/gotd/td/tg"
+	"gorm.io/gorm"
+)
+
+// UserDriver implements Session on top of an MTProto user account (gotd/td),
+// so an agent can operate as a regular Telegram user instead of a bot. It is
+// selected when TelegramConfig["mode"] == "user".
+type UserDriver struct {
+	agentID string
+	db      *gorm.DB
+	cfg     *config.Config
+	storage *memSessionStorage
+	client  *telegram.Client
+	cancel  context.CancelFunc
+	updates chan IncomingMessage
+
+	// closeMu guards against closing updates while onNewMessage is mid-send;
+	// see BotDriver.send for the same pattern.
+	closeMu sync.RWMutex
+	closed  bool
+
+	// peers remembers which kind of peer (user/chat/channel) each ChatID we've
+	// seen an incoming message from refers to, so Send/Edit can address the
+	// reply with the matching InputPeer* instead of always assuming a DM.
+	peersMu sync.RWMutex
+	peers   map[int64]peerRef
+}
+
+// peerRef is enough of a resolved MTProto peer to build an InputPeer* for a
+// reply: its kind, its ID, and (for users/channels) the access hash Telegram
+// requires to address it.
+type peerRef struct {
+	kind       peerKind
+	id         int64
+	accessHash int64
+}
+
+type peerKind int
+
+const (
+	peerKindUser peerKind = iota
+	peerKindChat
+	peerKindChannel
+)
+
+func newUserDriver(db *gorm.DB, cfg *config.Config, agent *store.Agent) (*UserDriver, error) {
+	apiID, apiHash, sessionB64, err := userCredsFromConfig(agent.TelegramConfig)
+	if err != nil {
+		return nil, err
+	}
+
+	if stored, err := loadAgentSession(db, cfg, agent.ID); err == nil && stored != "" {
+		sessionB64 = stored
+	}
+
+	storage, err := newMemSessionStorage(sessionB64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid telegram user session: %w", err)
+	}
+
+	d := &UserDriver{
+		agentID: agent.ID,
+		db:      db,
+		cfg:     cfg,
+		storage: storage,
+		updates: make(chan IncomingMessage, 64),
+		peers:   make(map[int64]peerRef),
+	}
+
+	dispatcher := tg.NewUpdateDispatcher()
+	dispatcher.OnNewMessage(d.onNewMessage)
+	gaps := updates.New(updates.Config{Handler: dispatcher})
+
+	d.client = telegram.NewClient(apiID, apiHash, telegram.Options{
+		SessionStorage: storage,
+		UpdateHandler:  gaps,
+	})
+
+	return d, nil
+}
+
+func (d *UserDriver) Start(ctx context.Context) error {
+	runCtx, cancel := context.WithCancel(ctx)
+	d.cancel = cancel
+
+	ready := make(chan error, 1)
+	go func() {
+		err := d.client.Run(runCtx, func(ctx context.Context) error {
+			status, err := d.client.Auth().Status(ctx)
+			if err != nil {
+				ready <- fmt.Errorf("failed to check telegram user auth status: %w", err)
+				return nil
+			}
+			if !status.Authorized {
+				ready <- fmt.Errorf("telegram user session is not authorized, a fresh session string is required")
+				return nil
+			}
+
+			d.persistSession(ctx)
+			ready <- nil
+			<-ctx.Done()
+			return nil
+		})
+		if err != nil && runCtx.Err() == nil {
+			slog.Error("MTProto client stopped unexpectedly", "agent_id", d.agentID, "error", err)
+		}
+	}()
+
+	select {
+	case err := <-ready:
+		return err
+	case <-time.After(15 * time.Second):
+		cancel()
+		return fmt.Errorf("timed out connecting telegram user session")
+	}
+}
+
+func (d *UserDriver) onNewMessage(ctx context.Context, e tg.Entities, u *tg.UpdateNewMessage) error {
+	msg, ok := u.Message.(*tg.Message)
+	if !ok || msg.Out {
+		return nil
+	}
+
+	im := IncomingMessage{Text: msg.Message, MessageID: msg.ID}
+	var ref peerRef
+	switch peer := msg.PeerID.(type) {
+	case *tg.PeerUser:
+		im.ChatID = peer.UserID
+		im.UserID = peer.UserID
+		ref = peerRef{kind: peerKindUser, id: peer.UserID}
+		if usr, ok := e.Users[peer.UserID]; ok {
+			ref.accessHash = usr.AccessHash
+		}
+	case *tg.PeerChat:
+		im.ChatID = peer.ChatID
+		ref = peerRef{kind: peerKindChat, id: peer.ChatID}
+	case *tg.PeerChannel:
+		im.ChatID = peer.ChannelID
+		ref = peerRef{kind: peerKindChannel, id: peer.ChannelID}
+		if c, ok := e.Channels[peer.ChannelID]; ok {
+			ref.accessHash = c.AccessHash
+		}
+	}
+	if msg.ReplyTo != nil {
+		im.ReplyToID = int(msg.ReplyTo.ReplyToMsgID)
+	}
+	d.setPeer(im.ChatID, ref)
+
+	d.closeMu.RLock()
+	defer d.closeMu.RUnlock()
+	if d.closed {
+		return nil
+	}
+	select {
+	case d.updates <- im:
+	case <-ctx.Done():
+	}
+	return nil
+}
+
+func (d *UserDriver) Stop() error {
+	if d.cancel != nil {
+		d.cancel()
+	}
+
+	d.closeMu.Lock()
+	d.closed = true
+	close(d.updates)
+	d.closeMu.Unlock()
+
+	return nil
+}
+
+func (d *UserDriver) Send(chatID int64, text string) (int, error) {
+	randomID := time.Now().UnixNano()
+	updates, err := d.client.API().MessagesSendMessage(context.Background(), &tg.MessagesSendMessageRequest{
+		Peer:     d.inputPeer(chatID),
+		Message:  text,
+		RandomID: randomID,
+	})
+	if err != nil {
+		return 0, err
+	}
+	return extractMessageID(updates, randomID), nil
+}
+
+func (d *UserDriver) Edit(chatID int64, messageID int, text string) error {
+	_, err := d.client.API().MessagesEditMessage(context.Background(), &tg.MessagesEditMessageRequest{
+		Peer:    d.inputPeer(chatID),
+		ID:      messageID,
+		Message: text,
+	})
+	return err
+}
+
+// setPeer remembers which kind of peer a ChatID refers to, learned from an
+// incoming message, so a later reply to that same ChatID addresses the
+// right InputPeer*.
+func (d *UserDriver) setPeer(chatID int64, ref peerRef) {
+	d.peersMu.Lock()
+	defer d.peersMu.Unlock()
+	d.peers[chatID] = ref
+}
+
+// inputPeer builds the InputPeer* for chatID matching how we last saw it
+// addressed. Falls back to InputPeerUser for a chat we've never received a
+// message from, e.g. an admin DM'd first.
+func (d *UserDriver) inputPeer(chatID int64) tg.InputPeerClass {
+	d.peersMu.RLock()
+	ref, ok := d.peers[chatID]
+	d.peersMu.RUnlock()
+	if !ok {
+		return &tg.InputPeerUser{UserID: chatID}
+	}
+
+	switch ref.kind {
+	case peerKindChat:
+		return &tg.InputPeerChat{ChatID: ref.id}
+	case peerKindChannel:
+		return &tg.InputPeerChannel{ChannelID: ref.id, AccessHash: ref.accessHash}
+	default:
+		return &tg.InputPeerUser{UserID: ref.id, AccessHash: ref.accessHash}
+	}
+}
+
+// extractMessageID pulls the server-assigned message ID for randomID out of
+// the update list gotd returns from MessagesSendMessage.
+func extractMessageID(updates tg.UpdatesClass, randomID int64) int {
+	upds, ok := updates.(*tg.Updates)
+	if !ok {
+		return 0
+	}
+	for _, u := range upds.Updates {
+		if um, ok := u.(*tg.UpdateMessageID); ok && um.RandomID == randomID {
+			return um.ID
+		}
+	}
+	return 0
+}
+
+func (d *UserDriver) Updates() <-chan IncomingMessage {
+	return d.updates
+}
+
+func (d *UserDriver) WhoAmI(ctx context.Context) (int64, string, string, error) {
+	self, err := d.client.Self(ctx)
+	if err != nil {
+		return 0, "", "", err
+	}
+	fullName := self.FirstName
+	if self.LastName != "" {
+		fullName = self.FirstName + " " + self.LastName
+	}
+	return self.ID, self.Username, fullName, nil
+}
+
+func (d *UserDriver) persistSession(ctx context.Context) {
+	data := d.storage.export()
+	if len(data) == 0 {
+		return
+	}
+	enc, err := encryptSecret(d.cfg.TgSessionEncryptionKey, data)
+	if err != nil {
+		slog.Error("Failed to encrypt telegram user session", "agent_id", d.agentID, "error", err)
+		return
+	}
+	// Save issues a plain UPDATE when the primary key is already set, which
+	// silently no-ops for a brand-new agent with no prior agent_sessions row.
+	// Upsert instead, the same way updateAgentData does for agent_data.
+	var rec store.AgentSession
+	if err := d.db.FirstOrCreate(&rec, store.AgentSession{AgentID: d.agentID}).Error; err != nil {
+		slog.Error("Failed to persist telegram user session", "agent_id", d.agentID, "error", err)
+		return
+	}
+	if err := d.db.Model(&store.AgentSession{}).Where("agent_id = ?", d.agentID).Update("encrypted_session", enc).Error; err != nil {
+		slog.Error("Failed to persist telegram user session", "agent_id", d.agentID, "error", err)
+	}
+}
+
+// memSessionStorage is a gotd session.Storage seeded from (and exported back
+// to) the encrypted blob kept in agent_sessions.
+type memSessionStorage struct {
+	mu   sync.Mutex
+	data []byte
+}
+
+func newMemSessionStorage(sessionB64 string) (*memSessionStorage, error) {
+	s := &memSessionStorage{}
+	if sessionB64 == "" {
+		return s, nil
+	}
+	data, err := decodeSession(sessionB64)
+	if err != nil {
+		return nil, err
+	}
+	s.data = data
+	return s, nil
+}
+
+func (s *memSessionStorage) LoadSession(ctx context.Context) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.data) == 0 {
+		return nil, session.ErrNotFound
+	}
+	return s.data, nil
+}
+
+func (s *memSessionStorage) StoreSession(ctx context.Context, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.data = data
+	return nil
+}
+
+func (s *memSessionStorage) export() []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.data
+}
+
+func userCredsFromConfig(cfg map[string]interface{}) (int, string, string, error) {
+	apiIDRaw, ok := cfg["api_id"]
+	if !ok {
+		return 0, "", "", fmt.Errorf("missing api_id for telegram user mode")
+	}
+	apiID, err := toInt(apiIDRaw)
+	if err != nil {
+		return 0, "", "", fmt.Errorf("invalid api_id: %w", err)
+	}
+
+	apiHash, _ := cfg["api_hash"].(string)
+	if apiHash == "" {
+		return 0, "", "", fmt.Errorf("missing api_hash for telegram user mode")
+	}
+
+	sessionStr, _ := cfg["session"].(string)
+	return apiID, apiHash, sessionStr, nil
+}
+
+func toInt(v interface{}) (int, error) {
+	switch n := v.(type) {
+	case float64:
+		return int(n), nil
+	case int:
+		return n, nil
+	case string:
+		return strconv.Atoi(n)
+	default:
+		return 0, fmt.Errorf("unsupported type %T", v)
+	}
+}
+
+func loadAgentSession(db *gorm.DB, cfg *config.Config, agentID string) (string, error) {
+	var rec store.AgentSession
+	if err := db.Where("agent_id = ?", agentID).First(&rec).Error; err != nil {
+		return "", err
+	}
+	data, err := decryptSecret(cfg.TgSessionEncryptionKey, rec.EncryptedSession)
+	if err != nil {
+		return "", err
+	}
+	return encodeSession(data), nil
+}
@@ -0,0 +1,97 @@
+package bot
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+
+	"github.com/mymmrac/telego"
+)
+
+// webhookServer is a single HTTP server shared by every agent running in
+// webhook mode. Incoming updates are dispatched to the right agent by
+// matching the request path against the per-agent secret route, instead of
+// running one long-poll goroutine per bot.
+type webhookServer struct {
+	mu     sync.RWMutex
+	routes map[string]webhookRoute
+	srv    *http.Server
+}
+
+type webhookRoute struct {
+	agentID string
+	secret  string
+	onUpdate func(telego.Update)
+}
+
+func newWebhookServer(addr string) *webhookServer {
+	ws := &webhookServer{routes: make(map[string]webhookRoute)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/tg/", ws.handle)
+	ws.srv = &http.Server{Addr: addr, Handler: mux}
+	return ws
+}
+
+func (ws *webhookServer) start() {
+	go func() {
+		if err := ws.srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Webhook server stopped unexpectedly", "error", err)
+		}
+	}()
+	slog.Info("Webhook server listening", "addr", ws.srv.Addr)
+}
+
+func (ws *webhookServer) stop(ctx context.Context) {
+	if err := ws.srv.Shutdown(ctx); err != nil {
+		slog.Error("Failed to shut down webhook server", "error", err)
+	}
+}
+
+func (ws *webhookServer) register(agentID, secret string, onUpdate func(telego.Update)) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	ws.routes[webhookPath(agentID, secret)] = webhookRoute{
+		agentID:  agentID,
+		secret:   secret,
+		onUpdate: onUpdate,
+	}
+}
+
+func (ws *webhookServer) unregister(agentID, secret string) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+	delete(ws.routes, webhookPath(agentID, secret))
+}
+
+func webhookPath(agentID, secret string) string {
+	return fmt.Sprintf("/tg/%s/%s", agentID, secret)
+}
+
+func (ws *webhookServer) handle(w http.ResponseWriter, r *http.Request) {
+	ws.mu.RLock()
+	route, ok := ws.routes[r.URL.Path]
+	ws.mu.RUnlock()
+
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if route.secret != "" && r.Header.Get("X-Telegram-Bot-Api-Secret-Token") != route.secret {
+		http.Error(w, "invalid secret token", http.StatusUnauthorized)
+		return
+	}
+
+	var update telego.Update
+	if err := json.NewDecoder(r.Body).Decode(&update); err != nil {
+		slog.Error("Failed to decode webhook update", "agent_id", route.agentID, "error", err)
+		http.Error(w, "bad request", http.StatusBadRequest)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	route.onUpdate(update)
+}
@@ -23,6 +23,43 @@ type Config struct {
 
 	// Telegram
 	TgNewAgentPollInterval int `env:"TG_NEW_AGENT_POLL_INTERVAL" default:"10"`
+
+	// TgMode selects how updates are delivered: "polling" (default, one long-poll
+	// goroutine per agent) or "webhook" (single HTTPS endpoint for all agents).
+	TgMode                     string `env:"TG_MODE" default:"polling"`
+	TgWebhookBaseURL           string `env:"TG_WEBHOOK_BASE_URL"`
+	TgWebhookListenAddr        string `env:"TG_WEBHOOK_LISTEN_ADDR" default:":8443"`
+	TgWebhookFallbackToPolling bool   `env:"TG_WEBHOOK_FALLBACK_TO_POLLING" default:"true"`
+
+	// TgSessionEncryptionKey is a 32-byte AES-256 key (hex or base64) used to
+	// encrypt MTProto user-account sessions at rest in agent_sessions.
+	TgSessionEncryptionKey string `env:"TG_SESSION_ENCRYPTION_KEY"`
+
+	// TgHistoryRetentionDays controls how long message_links rows are kept
+	// before the periodic pruning job removes them. 0 disables pruning.
+	TgHistoryRetentionDays int `env:"TG_HISTORY_RETENTION_DAYS" default:"30"`
+
+	// Multimodal attachments (voice, photos, documents)
+	TgMaxAttachmentSizeMB        int    `env:"TG_MAX_ATTACHMENT_SIZE_MB" default:"20"`
+	TgAllowedAttachmentMimeTypes string `env:"TG_ALLOWED_ATTACHMENT_MIME_TYPES" default:"image/jpeg,image/png,image/webp,audio/ogg,audio/mpeg,application/pdf"`
+
+	// OpenAIAPIKey enables OpenAIWhisperTranscriber for voice/audio messages.
+	// Voice notes are forwarded as attachments, untranscribed, when unset.
+	OpenAIAPIKey string `env:"OPENAI_API_KEY"`
+
+	// Per-agent rate limiting defaults, overridable per-agent via
+	// TelegramConfig["rate_limit_per_minute"] / ["rate_limit_burst"].
+	TgDefaultRateLimitPerMinute int `env:"TG_DEFAULT_RATE_LIMIT_PER_MINUTE" default:"20"`
+	TgDefaultRateLimitBurst     int `env:"TG_DEFAULT_RATE_LIMIT_BURST" default:"5"`
+
+	// TgAdminChallengeTTLMinutes bounds how long an admin has to reply with a
+	// TOTP code after a sensitive command DMs them a challenge.
+	TgAdminChallengeTTLMinutes int `env:"TG_ADMIN_CHALLENGE_TTL_MINUTES" default:"2"`
+}
+
+// MaxAttachmentSizeBytes converts TgMaxAttachmentSizeMB to bytes.
+func (c *Config) MaxAttachmentSizeBytes() int64 {
+	return int64(c.TgMaxAttachmentSizeMB) * 1024 * 1024
 }
 
 func Load() (*Config, error) {
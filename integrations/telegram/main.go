@@ -13,6 +13,7 @@ import (
 	"github.com/crestalnetwork/intentkit/integrations/telegram/api"
 	"github.com/crestalnetwork/intentkit/integrations/telegram/bot"
 	"github.com/crestalnetwork/intentkit/integrations/telegram/config"
+	"github.com/crestalnetwork/intentkit/integrations/telegram/store"
 )
 
 func main() {
@@ -50,7 +51,13 @@ func main() {
 	// But minimal migration for new tables or ensuring columns exist is fine.
 	// We only strictly need Agent and AgentData read access, and AgentData write access.
 	// For safety, we won't auto-migrate Agent table as it is core. AgentData is also core.
-	// So we skip auto-migration to avoid altering core tables unexpectedly.
+	// So we skip auto-migration for those. The tables this integration owns outright
+	// (agent_sessions, message_links, agent_admins) have no migration anywhere else in
+	// the main repo, so we create/update them here.
+	if err := db.AutoMigrate(&store.AgentSession{}, &store.MessageLink{}, &store.AgentAdmin{}); err != nil {
+		slog.Error("Failed to auto-migrate telegram integration tables", "error", err)
+		os.Exit(1)
+	}
 
 	// Initialize API Client
 	apiClient := api.NewClient(cfg.InternalBaseURL)
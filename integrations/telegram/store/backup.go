@@ -0,0 +1,31 @@
+package store
+
+import (
+	"encoding/json"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DumpMessageLinks serializes every MessageLink for an agent to JSON, for
+// backup before pruning or migrating between databases.
+func DumpMessageLinks(db *gorm.DB, agentID string) ([]byte, error) {
+	var links []MessageLink
+	if err := db.Where("agent_id = ?", agentID).Find(&links).Error; err != nil {
+		return nil, err
+	}
+	return json.Marshal(links)
+}
+
+// LoadMessageLinks restores MessageLinks from a DumpMessageLinks blob. Rows
+// whose primary key already exists are left untouched.
+func LoadMessageLinks(db *gorm.DB, data []byte) error {
+	var links []MessageLink
+	if err := json.Unmarshal(data, &links); err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&links).Error
+}
@@ -20,6 +20,19 @@ func (Agent) TableName() string {
 	return "agents"
 }
 
+// AgentSession stores the encrypted MTProto session for agents running in
+// Telegram "user" mode, so restarts don't force re-authentication.
+type AgentSession struct {
+	AgentID          string `gorm:"primaryKey;type:varchar"`
+	EncryptedSession string `gorm:"type:text"`
+	UpdatedAt        time.Time
+}
+
+// TableName overrides the table name for AgentSession
+func (AgentSession) TableName() string {
+	return "agent_sessions"
+}
+
 // AgentData represents the runtime data for an agent.
 type AgentData struct {
 	ID               string `gorm:"primaryKey;type:varchar"`
@@ -32,3 +45,39 @@ type AgentData struct {
 func (AgentData) TableName() string {
 	return "agent_data"
 }
+
+// MessageLink records one turn of a Telegram conversation so reply chains
+// can be walked back into history. A user replying to an older bot message
+// (ParentMessageID) lets handleMessage reconstruct that branch instead of
+// only ever continuing the latest turn.
+type MessageLink struct {
+	ID              uint   `gorm:"primaryKey;autoIncrement"`
+	AgentID         string `gorm:"index:idx_message_links_lookup;type:varchar"`
+	ChatID          string `gorm:"index:idx_message_links_lookup;type:varchar"`
+	MessageID       int    `gorm:"index:idx_message_links_lookup"`
+	ParentMessageID *int
+	Role            string // "user" or "assistant"
+	Text            string
+	CoreMessageID   string
+	CreatedAt       time.Time
+}
+
+// TableName overrides the table name for MessageLink
+func (MessageLink) TableName() string {
+	return "message_links"
+}
+
+// AgentAdmin stores the TOTP seed for one Telegram admin of one agent, used
+// to gate sensitive commands (e.g. /broadcast) behind a 6-digit DM challenge.
+// EncryptedTOTPSeed is sealed the same way AgentSession.EncryptedSession is.
+type AgentAdmin struct {
+	AgentID           string `gorm:"primaryKey;type:varchar"`
+	UserID            int64  `gorm:"primaryKey"`
+	EncryptedTOTPSeed string `gorm:"type:text"`
+	CreatedAt         time.Time
+}
+
+// TableName overrides the table name for AgentAdmin
+func (AgentAdmin) TableName() string {
+	return "agent_admins"
+}